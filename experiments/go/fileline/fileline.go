@@ -0,0 +1,75 @@
+// Package fileline provides a small line-oriented reader used to replace
+// the 8-byte-chunk loop in the httpgo demo, which treated a zero-length
+// read as "continue" instead of EOF and dropped data whenever a chunk
+// contained more than one newline.
+package fileline
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// Line is a single line read from a Scanner or Tailer. Err is set, with
+// Bytes empty, when the read failed; the channel is closed right after.
+type Line struct {
+	Offset int64
+	Bytes  []byte
+	Err    error
+}
+
+// Options configures a Scanner. A Tailer takes TailOptions instead.
+type Options struct {
+	// BufferSize sizes the internal read buffer. Zero uses bufio's default.
+	BufferSize int
+}
+
+// Scanner reads complete lines from r until EOF, handling both "\n" and
+// "\r\n" terminators. Unlike the loop it replaces, a trailing line with
+// no final newline is still emitted, every line of a read that holds
+// several newlines is kept, and EOF ends the read.
+type Scanner struct {
+	r      *bufio.Reader
+	offset int64
+}
+
+// NewScanner wraps r in a Scanner. A positive opts.BufferSize sets the
+// read buffer size; the zero value uses bufio's default.
+func NewScanner(r io.Reader, opts Options) *Scanner {
+	if opts.BufferSize > 0 {
+		return &Scanner{r: bufio.NewReaderSize(r, opts.BufferSize)}
+	}
+	return &Scanner{r: bufio.NewReader(r)}
+}
+
+// Lines starts reading in a goroutine and returns a channel of the lines
+// read, in order, closed once the source is exhausted or errors.
+func (s *Scanner) Lines() <-chan Line {
+	out := make(chan Line)
+	go func() {
+		defer close(out)
+		for {
+			start := s.offset
+			raw, err := s.r.ReadBytes('\n')
+			s.offset += int64(len(raw))
+
+			if len(raw) > 0 {
+				out <- Line{Offset: start, Bytes: trimNewline(raw)}
+			}
+
+			if err != nil {
+				if err != io.EOF {
+					out <- Line{Offset: s.offset, Err: err}
+				}
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func trimNewline(b []byte) []byte {
+	b = bytes.TrimSuffix(b, []byte("\n"))
+	b = bytes.TrimSuffix(b, []byte("\r"))
+	return b
+}
@@ -0,0 +1,133 @@
+package fileline
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// TailOptions configures a Tailer.
+type TailOptions struct {
+	// PollInterval is how often the file is polled for new data and for
+	// rotation. Defaults to 500ms.
+	PollInterval time.Duration
+}
+
+// Tailer follows a file the way `tail -F` does: it starts at the current
+// end of the file, emits complete lines as they are appended, and
+// transparently reopens the file when it is truncated in place or
+// renamed out from under it (e.g. by logrotate).
+type Tailer struct {
+	path string
+	opts TailOptions
+}
+
+// NewTailer prepares a Tailer for path. The file is not opened until
+// Lines is called.
+func NewTailer(path string, opts TailOptions) *Tailer {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 500 * time.Millisecond
+	}
+	return &Tailer{path: path, opts: opts}
+}
+
+// Lines starts tailing in a goroutine and returns a channel of lines
+// appended to the file from this point on. The channel is closed only
+// on an unrecoverable error (e.g. the file cannot be reopened); callers
+// that want to stop tailing should simply stop reading from it and let
+// the goroutine exit at process shutdown, matching the rest of this
+// demo's lifecycle.
+func (t *Tailer) Lines() <-chan Line {
+	out := make(chan Line)
+	go t.run(out)
+	return out
+}
+
+func (t *Tailer) run(out chan<- Line) {
+	defer close(out)
+
+	file, err := os.Open(t.path)
+	if err != nil {
+		out <- Line{Err: err}
+		return
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		out <- Line{Err: err}
+		return
+	}
+	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+		out <- Line{Err: err}
+		return
+	}
+
+	reader := bufio.NewReader(file)
+	offset := stat.Size()
+	var partial []byte
+
+	ticker := time.NewTicker(t.opts.PollInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		for {
+			chunk, rerr := reader.ReadBytes('\n')
+			partial = append(partial, chunk...)
+			if rerr != nil {
+				if rerr != io.EOF {
+					out <- Line{Err: rerr}
+					return
+				}
+				// No complete line available yet; wait for the next poll.
+				break
+			}
+
+			start := offset
+			offset += int64(len(partial))
+			out <- Line{Offset: start, Bytes: append([]byte(nil), trimNewline(partial)...)}
+			partial = nil
+		}
+
+		rotated, newStat, rerr := fileWasRotated(t.path, stat)
+		if rerr != nil {
+			out <- Line{Err: rerr}
+			return
+		}
+		stat = newStat
+
+		if !rotated {
+			continue
+		}
+
+		file.Close()
+		reopened, err := os.Open(t.path)
+		if err != nil {
+			out <- Line{Err: fmt.Errorf("reopen after rotation: %w", err)}
+			return
+		}
+		file = reopened
+		reader = bufio.NewReader(file)
+		offset = 0
+		partial = nil
+	}
+}
+
+// fileWasRotated reports whether path now refers to a different inode
+// than prev (renamed/recreated, as logrotate does), or has shrunk below
+// the size we last saw (truncated in place). It also returns the fresh
+// stat of path, which the caller keeps for the comparison on the next poll.
+func fileWasRotated(path string, prev os.FileInfo) (bool, os.FileInfo, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return false, nil, err
+	}
+
+	if !os.SameFile(prev, stat) {
+		return true, stat, nil
+	}
+	if stat.Size() < prev.Size() {
+		return true, stat, nil
+	}
+	return false, stat, nil
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+const metadataRefreshInterval = 30 * time.Second
+
+// groupHandler implements sarama.ConsumerGroupHandler. It commits offsets
+// per message via session.MarkMessage and sends a notification when a
+// session's partitions are assigned (Setup) and revoked (Cleanup), built
+// on top of the upstream ConsumerGroup session lifecycle. Members joining
+// or leaving the group are not reported as notifications.
+type groupHandler struct {
+	notifications chan<- string
+}
+
+func (h *groupHandler) Setup(session sarama.ConsumerGroupSession) error {
+	h.notifications <- "rebalance: partitions assigned " + formatClaims(session.Claims())
+	return nil
+}
+
+func (h *groupHandler) Cleanup(session sarama.ConsumerGroupSession) error {
+	h.notifications <- "rebalance: partitions revoked " + formatClaims(session.Claims())
+	return nil
+}
+
+func (h *groupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for {
+		select {
+		case message, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			log.Printf("group message topic=%s partition=%d offset=%d hwm=%d: %s\n",
+				message.Topic, message.Partition, message.Offset, claim.HighWaterMarkOffset(), string(message.Value))
+			session.MarkMessage(message, "")
+		case <-session.Context().Done():
+			return nil
+		}
+	}
+}
+
+func formatClaims(claims map[string][]int32) string {
+	var parts []string
+	for topic, partitions := range claims {
+		parts = append(parts, topic+"="+partitionsString(partitions))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func partitionsString(partitions []int32) string {
+	strs := make([]string, len(partitions))
+	for i, p := range partitions {
+		strs[i] = strconv.Itoa(int(p))
+	}
+	return strings.Join(strs, ",")
+}
+
+// resolveTopics returns the literal -group-topics list, or every topic
+// on the cluster matching -group-topics-regexp when that flag is set.
+func resolveTopics(client sarama.Client) ([]string, error) {
+	if *groupTopicRegexp == "" {
+		return strings.Split(*groupTopics, ","), nil
+	}
+
+	re, err := regexp.Compile(*groupTopicRegexp)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.RefreshMetadata(); err != nil {
+		return nil, err
+	}
+
+	all, err := client.Topics()
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []string
+	for _, t := range all {
+		if re.MatchString(t) {
+			matched = append(matched, t)
+		}
+	}
+	return matched, nil
+}
+
+// runConsumerGroup runs the demo in -mode=group: it joins a consumer
+// group, logs rebalance notifications, and re-resolves regex topic
+// subscriptions on a periodic metadata refresh until SIGINT/SIGTERM.
+func runConsumerGroup(config *sarama.Config) {
+	client, err := sarama.NewClient(brokers(), config)
+	if err != nil {
+		log.Println("failed to create client:", err)
+		return
+	}
+	defer client.Close()
+
+	group, err := sarama.NewConsumerGroupFromClient(*groupID, client)
+	if err != nil {
+		log.Println("failed to create consumer group:", err)
+		return
+	}
+	defer group.Close()
+
+	notifications := make(chan string, 16)
+	go func() {
+		for n := range notifications {
+			log.Println(n)
+		}
+	}()
+
+	shutdownCtx, shutdown := context.WithCancel(context.Background())
+	defer shutdown()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		log.Println("shutting down consumer group")
+		shutdown()
+	}()
+
+	go func() {
+		for err := range group.Errors() {
+			log.Println("consumer group error:", err)
+		}
+	}()
+
+	rejoin := make(chan struct{}, 1)
+	if *groupTopicRegexp != "" {
+		go refreshRegexSubscription(shutdownCtx, client, rejoin)
+	}
+
+	handler := &groupHandler{notifications: notifications}
+
+	for shutdownCtx.Err() == nil {
+		topics, err := resolveTopics(client)
+		if err != nil {
+			log.Println("failed to resolve topics:", err)
+			return
+		}
+
+		sessionCtx, cancelSession := context.WithCancel(shutdownCtx)
+		go func() {
+			select {
+			case <-rejoin:
+				log.Println("topic subscription changed, triggering rebalance")
+				cancelSession()
+			case <-sessionCtx.Done():
+			}
+		}()
+
+		if err := group.Consume(sessionCtx, topics, handler); err != nil {
+			if err == sarama.ErrClosedConsumerGroup {
+				cancelSession()
+				return
+			}
+			log.Println("consumer group session error:", err)
+		}
+		cancelSession()
+	}
+}
+
+// refreshRegexSubscription periodically re-evaluates -group-topics-regexp
+// against the cluster's topic list and signals rejoin when the matched
+// set changes, so the next Consume call picks up the new topics -
+// mirroring sarama-cluster's auto-discovery behaviour.
+func refreshRegexSubscription(ctx context.Context, client sarama.Client, rejoin chan<- struct{}) {
+	ticker := time.NewTicker(metadataRefreshInterval)
+	defer ticker.Stop()
+
+	current, _ := resolveTopics(client)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			next, err := resolveTopics(client)
+			if err != nil {
+				log.Println("failed to refresh topic metadata:", err)
+				continue
+			}
+			if !sameTopics(current, next) {
+				current = next
+				select {
+				case rejoin <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}
+}
+
+func sameTopics(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[string]bool, len(a))
+	for _, t := range a {
+		seen[t] = true
+	}
+	for _, t := range b {
+		if !seen[t] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+var (
+	workerCount = flag.Int("workers", 4, "number of worker goroutines processing messages, used in -mode=consume")
+	offsetFlag  = flag.String("offset", "newest", "where to start consuming: oldest, newest, or a literal offset, used in -mode=consume")
+	metricsAddr = flag.String("metrics-addr", ":9090", "address to serve /metrics on, used in -mode=consume")
+)
+
+// partitionMetrics tracks the counters and high-water-mark lag exposed on
+// /metrics for one partition.
+type partitionMetrics struct {
+	processed  atomic.Int64
+	errors     atomic.Int64
+	lag        atomic.Int64
+	lastOffset atomic.Int64
+}
+
+// consumeMetrics is the process-wide registry of per-partition metrics,
+// rendered as Prometheus text format by serveMetrics.
+type consumeMetrics struct {
+	mu         sync.Mutex
+	partitions map[int32]*partitionMetrics
+}
+
+func newConsumeMetrics() *consumeMetrics {
+	return &consumeMetrics{partitions: make(map[int32]*partitionMetrics)}
+}
+
+func (m *consumeMetrics) forPartition(partition int32) *partitionMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pm, ok := m.partitions[partition]
+	if !ok {
+		pm = &partitionMetrics{}
+		m.partitions[partition] = pm
+	}
+	return pm
+}
+
+func (m *consumeMetrics) writeTo(w http.ResponseWriter) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintln(w, "# HELP kafka_demo_messages_processed_total Messages processed per partition.")
+	fmt.Fprintln(w, "# TYPE kafka_demo_messages_processed_total counter")
+	for partition, pm := range m.partitions {
+		fmt.Fprintf(w, "kafka_demo_messages_processed_total{partition=\"%d\"} %d\n", partition, pm.processed.Load())
+	}
+
+	fmt.Fprintln(w, "# HELP kafka_demo_errors_total Consumer errors per partition.")
+	fmt.Fprintln(w, "# TYPE kafka_demo_errors_total counter")
+	for partition, pm := range m.partitions {
+		fmt.Fprintf(w, "kafka_demo_errors_total{partition=\"%d\"} %d\n", partition, pm.errors.Load())
+	}
+
+	fmt.Fprintln(w, "# HELP kafka_demo_consumer_lag High-water-mark lag per partition.")
+	fmt.Fprintln(w, "# TYPE kafka_demo_consumer_lag gauge")
+	for partition, pm := range m.partitions {
+		fmt.Fprintf(w, "kafka_demo_consumer_lag{partition=\"%d\"} %d\n", partition, pm.lag.Load())
+	}
+}
+
+func serveMetrics(addr string, metrics *consumeMetrics) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		metrics.writeTo(w)
+	})
+	log.Println("serving metrics on", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Println("metrics server error:", err)
+	}
+}
+
+func parseOffset(raw string) (int64, error) {
+	switch raw {
+	case "oldest":
+		return sarama.OffsetOldest, nil
+	case "newest":
+		return sarama.OffsetNewest, nil
+	default:
+		return strconv.ParseInt(raw, 10, 64)
+	}
+}
+
+// workerLane hashes a message key to a worker index so messages sharing
+// a key are always processed by the same worker, preserving per-key
+// ordering even though partitions are consumed in parallel.
+func workerLane(key []byte, workers int) int {
+	if len(key) == 0 || workers <= 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write(key)
+	return int(h.Sum32()) % workers
+}
+
+// runPartitionConsumer runs -mode=consume: one goroutine per partition
+// feeding a bounded worker pool, with per-partition high-water-mark lag
+// logged periodically and exposed on /metrics.
+func runPartitionConsumer(config *sarama.Config) {
+	if *workerCount < 1 {
+		log.Printf("invalid -workers=%d, must be >= 1\n", *workerCount)
+		return
+	}
+
+	offset, err := parseOffset(*offsetFlag)
+	if err != nil {
+		log.Println("invalid -offset:", err)
+		return
+	}
+
+	client, err := sarama.NewClient(brokers(), config)
+	if err != nil {
+		log.Println("failed to create client:", err)
+		return
+	}
+	defer client.Close()
+
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		log.Println("failed to start consumer:", err)
+		return
+	}
+	defer consumer.Close()
+
+	partitions, err := consumer.Partitions(*topic)
+	if err != nil {
+		log.Println("failed to list partitions:", err)
+		return
+	}
+
+	metrics := newConsumeMetrics()
+	go serveMetrics(*metricsAddr, metrics)
+
+	lanes := make([]chan *sarama.ConsumerMessage, *workerCount)
+	for i := range lanes {
+		lanes[i] = make(chan *sarama.ConsumerMessage, 256)
+	}
+
+	var workerWG sync.WaitGroup
+	for i, lane := range lanes {
+		workerWG.Add(1)
+		go func(id int, lane <-chan *sarama.ConsumerMessage) {
+			defer workerWG.Done()
+			for msg := range lane {
+				pm := metrics.forPartition(msg.Partition)
+				pm.processed.Add(1)
+				log.Printf("worker %d processed partition=%d offset=%d key=%s\n", id, msg.Partition, msg.Offset, string(msg.Key))
+			}
+		}(i, lane)
+	}
+
+	var partitionWG sync.WaitGroup
+	partitionConsumers := make([]sarama.PartitionConsumer, 0, len(partitions))
+	for _, partition := range partitions {
+		pc, err := consumer.ConsumePartition(*topic, partition, offset)
+		if err != nil {
+			log.Printf("failed to consume partition %d: %v\n", partition, err)
+			continue
+		}
+		partitionConsumers = append(partitionConsumers, pc)
+
+		partitionWG.Add(1)
+		go func(partition int32, pc sarama.PartitionConsumer) {
+			defer partitionWG.Done()
+			pm := metrics.forPartition(partition)
+			for msg := range pc.Messages() {
+				pm.lastOffset.Store(msg.Offset)
+				lanes[workerLane(msg.Key, *workerCount)] <- msg
+			}
+		}(partition, pc)
+
+		go func(partition int32, pc sarama.PartitionConsumer) {
+			pm := metrics.forPartition(partition)
+			for err := range pc.Errors() {
+				pm.errors.Add(1)
+				log.Printf("partition %d consumer error: %v\n", partition, err)
+			}
+		}(partition, pc)
+	}
+
+	stopLagReporter := make(chan struct{})
+	go reportLag(client, *topic, partitions, metrics, stopLagReporter)
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	<-sigCh
+
+	log.Println("shutting down partition consumer")
+	close(stopLagReporter)
+	for _, pc := range partitionConsumers {
+		pc.AsyncClose()
+	}
+	partitionWG.Wait()
+
+	for _, lane := range lanes {
+		close(lane)
+	}
+	workerWG.Wait()
+}
+
+// reportLag polls the high-water-mark for each partition and updates the
+// exposed lag gauge so operators can see backpressure building up.
+func reportLag(client sarama.Client, topic string, partitions []int32, metrics *consumeMetrics, stop <-chan struct{}) {
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			for _, partition := range partitions {
+				hwm, err := client.GetOffset(topic, partition, sarama.OffsetNewest)
+				if err != nil {
+					log.Printf("failed to fetch high-water-mark for partition %d: %v\n", partition, err)
+					continue
+				}
+				pm := metrics.forPartition(partition)
+				lag := hwm - pm.lastOffset.Load()
+				pm.lag.Store(lag)
+				log.Printf("partition %d lag=%d hwm=%d\n", partition, lag, hwm)
+			}
+		}
+	}
+}
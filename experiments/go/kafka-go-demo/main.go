@@ -1,86 +1,245 @@
 package main
 
 import (
-	"crypto/tls"
-	"crypto/x509"
-	"io"
+	"context"
+	"encoding/json"
+	"flag"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/IBM/sarama"
 )
 
-func main() {
-	keypair, err := tls.LoadX509KeyPair("./certs/service.cert", "./certs/service.key")
-	if err != nil {
-		log.Println(err)
+var (
+	mode           = flag.String("mode", "http", "run mode: \"http\" for the ingest bridge, \"group\" for the consumer-group subscriber, \"consume\" for the parallel per-partition consumer, \"tail\" for the file log-shipper")
+	addr           = flag.String("addr", ":8080", "the address to bind the HTTP server to")
+	brokerList     = flag.String("brokers", os.Getenv("KAFKA_PEERS"), "comma separated list of kafka brokers (falls back to KAFKA_PEERS)")
+	topic          = flag.String("topic", "demo", "kafka topic to forward ingested messages to")
+	accessLogTopic = flag.String("access-log-topic", "demo.access-log", "kafka topic to stream access-log entries to")
+
+	groupID          = flag.String("group", "demo-group", "consumer group id, used in -mode=group")
+	groupTopics      = flag.String("group-topics", "demo", "comma separated list of topics to subscribe to in -mode=group")
+	groupTopicRegexp = flag.String("group-topics-regexp", "", "if set, subscribe to every topic matching this regexp instead of -group-topics, re-resolved on each metadata refresh")
+
+	authMode     = flag.String("auth", "tls", "authentication mode: plaintext, tls, sasl_plaintext or sasl_ssl")
+	certFile     = flag.String("cert", "./certs/service.cert", "path to the client certificate, used in -auth=tls")
+	keyFile      = flag.String("key", "./certs/service.key", "path to the client key, used in -auth=tls")
+	caFile       = flag.String("ca", "./certs/ca.pem", "path to the CA certificate, used in -auth=tls and -auth=sasl_ssl")
+	verify       = flag.Bool("verify", true, "verify the broker's certificate; set false for local/self-signed brokers")
+	saslUser     = flag.String("username", "", "SASL username, used in -auth=sasl_plaintext and -auth=sasl_ssl")
+	saslPassword = flag.String("password", "", "SASL password, used in -auth=sasl_plaintext and -auth=sasl_ssl")
+	saslMech     = flag.String("sasl-mechanism", "plain", "SASL mechanism: plain or scram")
+	algorithm    = flag.String("algorithm", "sha-256", "SCRAM algorithm: sha-256 or sha-512, used when -sasl-mechanism=scram")
+)
+
+const defaultBroker = "kafka-go-demo-johnleoclaudio-af60.d.aivencloud.com:17379"
+
+// accessLogEntry is the shape of the fire-and-forget record sent to the
+// access log topic for every request handled by the server.
+type accessLogEntry struct {
+	Method   string  `json:"method"`
+	Path     string  `json:"path"`
+	ClientIP string  `json:"client_ip"`
+	Status   int     `json:"status"`
+	ElapsedS float64 `json:"elapsed_s"`
+}
+
+// server bundles the two producers a request handler needs: a sync
+// producer so the HTTP response can report the resulting partition and
+// offset, and an async producer for the access-log stream.
+type server struct {
+	syncProducer  sarama.SyncProducer
+	asyncProducer sarama.AsyncProducer
+	topic         string
+	accessTopic   string
+}
+
+func brokers() []string {
+	if *brokerList == "" {
+		return []string{defaultBroker}
+	}
+	return strings.Split(*brokerList, ",")
+}
+
+// handleMessages ingests a JSON payload and forwards it to the configured
+// Kafka topic via the sync producer, reporting the resulting partition
+// and offset in the response. A non-2xx is returned when the send fails.
+func (s *server) handleMessages(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	status := http.StatusOK
+
+	defer func() {
+		s.logAccess(r, status, time.Since(start))
+	}()
+
+	if r.Method != http.MethodPost {
+		status = http.StatusMethodNotAllowed
+		http.Error(w, "only POST is supported", status)
 		return
 	}
 
-	file, err := os.Open("./certs/ca.pem")
-	if err != nil {
-		log.Println(err)
+	var payload map[string]any
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&payload); err != nil {
+		status = http.StatusBadRequest
+		http.Error(w, "invalid JSON payload: "+err.Error(), status)
 		return
 	}
-	defer file.Close()
 
-	caCert, err := io.ReadAll(file)
+	value, err := json.Marshal(payload)
 	if err != nil {
-		log.Println(err)
+		status = http.StatusInternalServerError
+		http.Error(w, err.Error(), status)
 		return
 	}
-	caCertPool := x509.NewCertPool()
-	caCertPool.AppendCertsFromPEM(caCert)
 
-	tlsConfig := &tls.Config{
-		Certificates: []tls.Certificate{keypair},
-		RootCAs:      caCertPool,
+	partition, offset, err := s.syncProducer.SendMessage(&sarama.ProducerMessage{
+		Topic: s.topic,
+		Value: sarama.ByteEncoder(value),
+	})
+	if err != nil {
+		status = http.StatusInternalServerError
+		log.Println("failed to send message:", err)
+		http.Error(w, "failed to send message: "+err.Error(), status)
+		return
 	}
 
-	// init config, enable error and notifications
-	config := sarama.NewConfig()
-	config.Producer.Return.Successes = true
-	config.Net.TLS.Enable = true
-	config.Net.TLS.Config = tlsConfig
-	config.Version = sarama.V2_5_0_0
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"partition": partition,
+		"offset":    offset,
+	})
+}
+
+// logAccess fires a single access-log message at the async producer and
+// does not wait for the result; delivery errors are drained separately.
+func (s *server) logAccess(r *http.Request, status int, elapsed time.Duration) {
+	entry := accessLogEntry{
+		Method:   r.Method,
+		Path:     r.URL.Path,
+		ClientIP: clientIP(r),
+		Status:   status,
+		ElapsedS: elapsed.Seconds(),
+	}
 
-	brokers := []string{"kafka-go-demo-johnleoclaudio-af60.d.aivencloud.com:17379"}
-	producer, err := sarama.NewSyncProducer(brokers, config)
+	value, err := json.Marshal(entry)
 	if err != nil {
-		log.Println("Failed to start Sarama producer:", err)
+		log.Println("failed to marshal access log entry:", err)
 		return
 	}
 
-	defer producer.Close()
+	s.asyncProducer.Input() <- &sarama.ProducerMessage{
+		Topic: s.accessTopic,
+		Value: sarama.ByteEncoder(value),
+	}
+}
 
-	producerMessage := &sarama.ProducerMessage{
-		Topic: "demo",
-		Key:   sarama.StringEncoder("key"),
-		Value: sarama.StringEncoder("Hello, World!"),
+func clientIP(r *http.Request) string {
+	if ip := r.Header.Get("X-Forwarded-For"); ip != "" {
+		return strings.Split(ip, ",")[0]
 	}
+	return r.RemoteAddr
+}
+
+func main() {
+	flag.Parse()
 
-	partition, offset, err := producer.SendMessage(producerMessage)
+	config, err := buildSaramaConfig()
 	if err != nil {
-		log.Println("Failed to send message:", err)
+		log.Println(err)
 		return
 	}
-	log.Printf("Message sent to partition %d at offset %d\n", partition, offset)
 
-	consumer, err := sarama.NewConsumer(brokers, config)
+	switch *mode {
+	case "group":
+		runConsumerGroup(config)
+		return
+	case "consume":
+		runPartitionConsumer(config)
+		return
+	case "tail":
+		runTailProducer(config)
+		return
+	}
+
+	syncProducer, err := sarama.NewSyncProducer(brokers(), config)
 	if err != nil {
-		log.Println("Failed to start Sarama consumer:", err)
+		log.Println("failed to start sync producer:", err)
 		return
 	}
-	defer consumer.Close()
 
-	partitionConsumer, err := consumer.ConsumePartition("demo", 0, sarama.OffsetOldest)
+	asyncProducer, err := sarama.NewAsyncProducer(brokers(), config)
 	if err != nil {
-		log.Println("Failed to start partition consumer:", err)
+		log.Println("failed to start async producer:", err)
+		syncProducer.Close()
 		return
 	}
-	defer partitionConsumer.Close()
 
-	for msg := range partitionConsumer.Messages() {
-		log.Printf("Consumed message offset %d: %s\n", msg.Offset, string(msg.Value))
+	go func() {
+		for err := range asyncProducer.Errors() {
+			log.Println("access log delivery failed:", err)
+		}
+	}()
+
+	// The shared config sets Return.Successes, so the async producer's
+	// Successes channel must be drained too or Input() eventually blocks.
+	go func() {
+		for range asyncProducer.Successes() {
+		}
+	}()
+
+	s := &server{
+		syncProducer:  syncProducer,
+		asyncProducer: asyncProducer,
+		topic:         *topic,
+		accessTopic:   *accessLogTopic,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/messages", s.handleMessages)
+
+	httpServer := &http.Server{
+		Addr:    *addr,
+		Handler: mux,
+	}
+
+	go func() {
+		log.Println("listening on", *addr)
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Println("http server error:", err)
+		}
+	}()
+
+	shutdown(httpServer, syncProducer, asyncProducer)
+}
+
+// shutdown blocks until SIGINT/SIGTERM, then closes the HTTP server and
+// both producers so in-flight sends and the access-log stream drain
+// before the process exits.
+func shutdown(httpServer *http.Server, syncProducer sarama.SyncProducer, asyncProducer sarama.AsyncProducer) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	<-sigCh
+
+	log.Println("shutting down")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := httpServer.Shutdown(ctx); err != nil {
+		log.Println("http server shutdown error:", err)
+	}
+
+	if err := asyncProducer.Close(); err != nil {
+		log.Println("async producer close error:", err)
+	}
+
+	if err := syncProducer.Close(); err != nil {
+		log.Println("sync producer close error:", err)
 	}
 }
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"strconv"
+
+	"github.com/IBM/sarama"
+	"github.com/zllec/lab/experiments/go/fileline"
+)
+
+var tailFile = flag.String("tail-file", "", "file to tail and ship to -topic, used in -mode=tail")
+
+// runTailProducer runs -mode=tail: a minimal log-shipper that tails
+// -tail-file the way `tail -F` does and produces each line to -topic,
+// keyed on the line's byte offset in the file.
+func runTailProducer(config *sarama.Config) {
+	if *tailFile == "" {
+		log.Println("-tail-file is required in -mode=tail")
+		return
+	}
+
+	producer, err := sarama.NewSyncProducer(brokers(), config)
+	if err != nil {
+		log.Println("failed to start producer:", err)
+		return
+	}
+	defer producer.Close()
+
+	tailer := fileline.NewTailer(*tailFile, fileline.TailOptions{})
+	for line := range tailer.Lines() {
+		if line.Err != nil {
+			log.Println("tail error:", line.Err)
+			return
+		}
+
+		partition, offset, err := producer.SendMessage(&sarama.ProducerMessage{
+			Topic: *topic,
+			Key:   sarama.StringEncoder(strconv.FormatInt(line.Offset, 10)),
+			Value: sarama.ByteEncoder(line.Bytes),
+		})
+		if err != nil {
+			log.Println("failed to send tailed line:", err)
+			continue
+		}
+		log.Printf("shipped line at file offset %d to partition %d offset %d\n", line.Offset, partition, offset)
+	}
+}